@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+)
+
+const (
+	minCompressionLevel     = flate.HuffmanOnly
+	maxCompressionLevel     = flate.BestCompression
+	defaultCompressionLevel = flate.BestSpeed
+
+	maxReadHistory = 32 * 1024
+)
+
+// flateSyncFlush is the 4-byte empty stored block flate.Writer.Flush always
+// ends a stream with. RFC 7692 section 7.2.1 requires senders to strip it
+// before putting the message on the wire.
+var flateSyncFlush = []byte{0x00, 0x00, 0xff, 0xff}
+
+// flateTail reconstructs flateSyncFlush for decompression and appends a
+// synthetic final empty block so the flate reader terminates cleanly
+// instead of returning io.ErrUnexpectedEOF.
+const flateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+// redirectWriter lets a persistent flate.Writer keep writing to the same
+// destination object across messages while the bytes actually buffered
+// there get swapped out per message. This is what lets a single
+// flate.Writer's compression state (its sliding window) survive across
+// messages for permessage-deflate context takeover.
+type redirectWriter struct {
+	w io.Writer
+}
+
+func (r *redirectWriter) Write(p []byte) (int, error) {
+	return r.w.Write(p)
+}
+
+// compressWriter wraps a message's io.WriteCloser so the bytes written to
+// it are deflated before being handed to the framer. The whole message is
+// deflated into memory first so the trailing sync-flush marker can be
+// trimmed and the result handed to the framer in one Write, matching how
+// msgWriter expects to be driven.
+type compressWriter struct {
+	c     *Conn
+	fw    *flate.Writer
+	buf   bytes.Buffer
+	under io.WriteCloser
+}
+
+func (c *Conn) newCompressWriter(under io.WriteCloser) *compressWriter {
+	w := &compressWriter{c: c, under: under}
+
+	if c.flateDst == nil {
+		c.flateDst = &redirectWriter{}
+	}
+	c.flateDst.w = &w.buf
+
+	switch {
+	case c.flateWriter == nil, c.compressionLevel != c.flateWriterLevel:
+		// flate.Writer.Reset doesn't take a level, so a level change made
+		// via SetCompressionLevel since the writer was created can only
+		// take effect by starting a fresh stream; this forfeits this
+		// connection's write-side context takeover window just this once.
+		c.flateWriter, _ = flate.NewWriter(c.flateDst, c.compressionLevel)
+		c.flateWriterLevel = c.compressionLevel
+	case c.writeNoContextTakeover:
+		c.flateWriter.Reset(c.flateDst)
+	}
+	w.fw = c.flateWriter
+
+	return w
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	return w.fw.Write(p)
+}
+
+func (w *compressWriter) Close() error {
+	if err := w.fw.Flush(); err != nil {
+		return err
+	}
+
+	payload := w.buf.Bytes()
+	if bytes.HasSuffix(payload, flateSyncFlush) {
+		payload = payload[:len(payload)-len(flateSyncFlush)]
+	}
+
+	if _, err := w.under.Write(payload); err != nil {
+		return err
+	}
+	return w.under.Close()
+}
+
+// compressedReader wraps a message's raw frame reader, inflating it as it's
+// read and feeding the decompressed bytes into the connection's read-side
+// history window for the next message's context takeover.
+type compressedReader struct {
+	c  *Conn
+	fr io.Reader
+}
+
+func (r *compressedReader) Read(p []byte) (int, error) {
+	n, err := r.fr.Read(p)
+	if n > 0 && !r.c.readNoContextTakeover {
+		r.c.readHistory = append(r.c.readHistory, p[:n]...)
+		if len(r.c.readHistory) > maxReadHistory {
+			r.c.readHistory = r.c.readHistory[len(r.c.readHistory)-maxReadHistory:]
+		}
+	}
+	return n, err
+}
+
+// newCompressedReader wraps src, a message's raw (still-compressed) frame
+// reader, inflating it with the previously decompressed window as a preset
+// dictionary unless read-side no-context-takeover was negotiated.
+func (c *Conn) newCompressedReader(src io.Reader) io.Reader {
+	var dict []byte
+	if !c.readNoContextTakeover {
+		dict = c.readHistory
+	}
+
+	full := io.MultiReader(src, strings.NewReader(flateTail))
+
+	if c.flateReader == nil {
+		c.flateReader = flate.NewReader(full)
+	} else {
+		c.flateReader.(flate.Resetter).Reset(full, dict)
+	}
+
+	return &compressedReader{c: c, fr: c.flateReader}
+}
+
+// EnableWriteCompression enables or disables permessage-deflate compression
+// for subsequent writes on this connection. It has no effect if compression
+// was not negotiated during the handshake.
+func (c *Conn) EnableWriteCompression(enable bool) {
+	c.enableWriteCompression = enable
+}
+
+// SetCompressionLevel sets the flate compression level used for outgoing
+// messages. See the compress/flate level constants; the default is
+// flate.BestSpeed.
+func (c *Conn) SetCompressionLevel(level int) error {
+	if level < minCompressionLevel || level > maxCompressionLevel {
+		return errBadCompressionLevel
+	}
+	c.compressionLevel = level
+	return nil
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCompressionRoundTrip exercises the full permessage-deflate path
+// end-to-end: negotiation during the handshake, compressWriter on the
+// sending side, and newCompressedReader on the receiving side.
+func TestCompressionRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("server upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		mt, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Errorf("server read: %v", err)
+			return
+		}
+		if err := conn.WriteMessage(mt, msg); err != nil {
+			t.Errorf("server write: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	conn, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if !conn.compressionEnabled {
+		t.Fatal("expected permessage-deflate to be negotiated")
+	}
+
+	want := strings.Repeat("the quick brown fox jumps over the lazy dog ", 50)
+	if err := conn.WriteMessage(TextMessage, []byte(want)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_, got, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("round trip mismatch: got %q, want %q", got, want)
+	}
+}
+
+// TestSetCompressionLevelAppliesToLaterMessages guards against
+// SetCompressionLevel silently being a no-op past the first message: once
+// context takeover reuses the flate.Writer, a later level change must
+// still take effect.
+func TestSetCompressionLevelAppliesToLaterMessages(t *testing.T) {
+	clientRaw, serverRaw := net.Pipe()
+	defer clientRaw.Close()
+	defer serverRaw.Close()
+
+	server := newConn(serverRaw, true, 0, 0)
+	server.setCompression(deflateParams{clientMaxWindowBits: 15, serverMaxWindowBits: 15})
+
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			if _, err := clientRaw.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := server.WriteMessage(TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	if server.flateWriterLevel != defaultCompressionLevel {
+		t.Fatalf("flateWriterLevel = %d, want %d (default)", server.flateWriterLevel, defaultCompressionLevel)
+	}
+
+	if err := server.SetCompressionLevel(maxCompressionLevel); err != nil {
+		t.Fatalf("SetCompressionLevel: %v", err)
+	}
+	if err := server.WriteMessage(TextMessage, []byte("world")); err != nil {
+		t.Fatalf("second write: %v", err)
+	}
+	if server.flateWriterLevel != maxCompressionLevel {
+		t.Fatalf("flateWriterLevel = %d, want %d after SetCompressionLevel", server.flateWriterLevel, maxCompressionLevel)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sushil-cmd-r/websocket"
+)
+
+// runClientSuite drives the websocket Dial client through every case the
+// fuzzingserver at baseURL offers, under agent, then asks it to write out
+// the aggregate report.
+func runClientSuite(baseURL, agent string) error {
+	count, err := getCaseCount(baseURL)
+	if err != nil {
+		return fmt.Errorf("getCaseCount: %w", err)
+	}
+
+	for i := 1; i <= count; i++ {
+		if err := runCase(baseURL, agent, i); err != nil {
+			return fmt.Errorf("case %d: %w", i, err)
+		}
+	}
+
+	return updateReports(baseURL, agent)
+}
+
+func getCaseCount(baseURL string) (int, error) {
+	conn, err := websocket.Dial(baseURL + "/getCaseCount")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(msg))
+}
+
+// runCase echoes whatever the server under test sends until it closes the
+// connection; a read error other than a clean close fails the case.
+func runCase(baseURL, agent string, caseIndex int) error {
+	url := fmt.Sprintf("%s/runCase?case=%d&agent=%s", baseURL, caseIndex, agent)
+	conn, err := websocket.Dial(url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		mt, msg, err := conn.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*websocket.CloseError); ok {
+				return nil
+			}
+			return err
+		}
+
+		if err := conn.WriteMessage(mt, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func updateReports(baseURL, agent string) error {
+	conn, err := websocket.Dial(fmt.Sprintf("%s/updateReports?agent=%s", baseURL, agent))
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,106 @@
+//go:build conformance
+
+// Package conformance runs this module against the Autobahn Testsuite
+// (https://github.com/crossbario/autobahn-testsuite), which exercises
+// fragmentation, UTF-8 validation, close-code edge cases, oversized
+// frames, and permessage-deflate far more exhaustively than is practical
+// to hand-write as unit tests.
+//
+// It requires the `wstest` binary (installed via `pip install
+// autobahntestsuite`, or the crossbario/autobahn-testsuite Docker image)
+// and is excluded from the default build via the "conformance" build tag:
+//
+//	go test -tags conformance ./conformance/...
+package conformance
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+const agent = "websocket-go"
+
+// waitForPort polls addr until a TCP connection succeeds or timeout elapses.
+func waitForPort(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+		time.Sleep(100 * time.Millisecond)
+	}
+	return lastErr
+}
+
+func requireWstest(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("wstest"); err != nil {
+		t.Skip("wstest not found in PATH; install the Autobahn Testsuite to run conformance tests")
+	}
+}
+
+// TestServerConformance runs our Conn as the connection under test against
+// wstest acting as the fuzzing client, covering Upgrader/Accept and the
+// server side of every negotiated extension.
+func TestServerConformance(t *testing.T) {
+	requireWstest(t)
+
+	shutdown, err := echoServer("127.0.0.1:9001")
+	if err != nil {
+		t.Fatalf("starting echo server: %v", err)
+	}
+	defer shutdown()
+
+	cmd := exec.Command("wstest", "-m", "fuzzingclient", "-s", "fuzzingclient.json")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wstest fuzzingclient: %v", err)
+	}
+
+	failed, err := checkReport("reports/clients", agent)
+	if err != nil {
+		t.Fatalf("checkReport: %v", err)
+	}
+	if len(failed) > 0 {
+		t.Fatalf("%d conformance case(s) regressed: %v (see reports/clients/index.json)", len(failed), failed)
+	}
+}
+
+// TestClientConformance runs our Dialer as the connection under test
+// against wstest acting as the fuzzing server, covering Dial and the
+// client side of every negotiated extension.
+func TestClientConformance(t *testing.T) {
+	requireWstest(t)
+
+	cmd := exec.Command("wstest", "-m", "fuzzingserver", "-s", "fuzzingserver.json")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting wstest fuzzingserver: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	if err := waitForPort("127.0.0.1:9001", 10*time.Second); err != nil {
+		t.Fatalf("wstest fuzzingserver never came up: %v", err)
+	}
+
+	if err := runClientSuite("ws://127.0.0.1:9001", agent); err != nil {
+		t.Fatalf("running client suite: %v", err)
+	}
+
+	failed, err := checkReport("reports/server", agent)
+	if err != nil {
+		t.Fatalf("checkReport: %v", err)
+	}
+	if len(failed) > 0 {
+		t.Fatalf("%d conformance case(s) regressed: %v (see reports/server/index.json)", len(failed), failed)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// caseResult is one entry of the "behavior"/"behaviorClose" pair wstest
+// records per test case in its index.json report.
+type caseResult struct {
+	Behavior      string `json:"behavior"`
+	BehaviorClose string `json:"behaviorClose"`
+}
+
+// passing behaviors per the Autobahn Testsuite's own classification; any
+// case landing outside this set (FAILED, WRONG_CODE, UNCLEAN, ...) is a
+// conformance regression.
+var passingBehaviors = map[string]bool{
+	"OK":            true,
+	"INFORMATIONAL": true,
+	"NON-STRICT":    true,
+}
+
+// checkReport reads outdir/index.json (the report wstest writes for agent)
+// and returns the case IDs that failed, if any.
+func checkReport(outdir, agent string) (failed []string, err error) {
+	path := filepath.Join(outdir, "index.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report map[string]map[string]caseResult
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	cases, ok := report[agent]
+	if !ok {
+		return nil, fmt.Errorf("no results for agent %q in %s", agent, path)
+	}
+
+	for id, result := range cases {
+		if !passingBehaviors[result.Behavior] || !passingBehaviors[result.BehaviorClose] {
+			failed = append(failed, id)
+		}
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,71 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/sushil-cmd-r/websocket"
+)
+
+// echoServer runs the connection under test for the server-side (fuzzing
+// client) conformance run: it upgrades every request and echoes back
+// whatever it receives, verbatim, until the peer closes the connection.
+func echoServer(addr string) (shutdown func(), err error) {
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Println("conformance: upgrade:", err)
+			return
+		}
+		defer conn.Close()
+
+		for {
+			mt, r, err := conn.NextReader()
+			if err != nil {
+				return
+			}
+
+			wr, err := conn.NextWriter(mt)
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(wr, r); err != nil {
+				wr.Close()
+				return
+			}
+			if err := wr.Close(); err != nil {
+				return
+			}
+		}
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	return func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Println("conformance: shutdown:", err)
+		}
+	}, waitForServe(errCh)
+}
+
+func waitForServe(errCh chan error) error {
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	default:
+		return nil
+	}
+}
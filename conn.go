@@ -2,14 +2,23 @@ package websocket
 
 import (
 	"bufio"
+	"compress/flate"
 	"encoding/binary"
 	"errors"
 	"io"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+// writeWait is the deadline applied to automatic control replies (pong
+// replies to pings, and close echoes) that don't go through a caller's own
+// WriteControlDeadline call.
+const writeWait = 1 * time.Second
+
 const (
 	finBit  = 1 << 7
 	rsv1Bit = 1 << 6
@@ -126,6 +135,7 @@ var (
 	errUnexpectedEOF       = &CloseError{Code: CloseAbnormalClosure, Text: io.ErrUnexpectedEOF.Error()}
 	errInvalidControlFrame = errors.New("websocket: invalid control frame")
 	errBadMessageCode      = errors.New("websocket: bad message code")
+	errBadCompressionLevel = errors.New("websocket: bad compression level")
 )
 
 type Conn struct {
@@ -136,24 +146,163 @@ type Conn struct {
 	writerSize int
 
 	isServer bool
+
+	// subprotocol is the application subprotocol negotiated at handshake
+	// time, or "" if none was requested or accepted.
+	subprotocol string
+
+	// permessage-deflate (RFC 7692) state, set once at handshake time.
+	compressionEnabled     bool
+	enableWriteCompression bool
+	compressionLevel       int
+	writeNoContextTakeover bool
+	readNoContextTakeover  bool
+
+	flateWriter *flate.Writer
+	// flateWriterLevel is the compressionLevel flateWriter was created
+	// with, so newCompressWriter can tell when SetCompressionLevel has
+	// changed the level since and the writer needs recreating.
+	flateWriterLevel int
+	flateDst         *redirectWriter
+	flateReader      io.ReadCloser
+	readHistory      []byte
+
+	// readLimit is the maximum size, in bytes, of a message NextReader
+	// will return before failing the connection. Zero means no limit.
+	readLimit int64
+
+	// writeMu serializes all writes to conn, so a control write (e.g. a
+	// pong sent from a keepalive goroutine) can't interleave its bytes
+	// with a data frame written concurrently via NextWriter.
+	writeMu sync.Mutex
+
+	// writeDeadline is the deadline last set via SetWriteDeadline, so
+	// WriteControlDeadline can restore it on conn after a control write
+	// temporarily overrides it with its own deadline.
+	writeDeadline time.Time
+
+	pingHandler  func(appData string) error
+	pongHandler  func(appData string) error
+	closeHandler func(code int, text string) error
+}
+
+func newConn(conn net.Conn, isServer bool, readBufSize, writeBufSize int) *Conn {
+	if readBufSize <= 0 {
+		readBufSize = defaultReaderSize
+	}
+	if writeBufSize <= 0 {
+		writeBufSize = defaultWriterSize
+	}
+
+	br := bufio.NewReaderSize(conn, readBufSize)
+	c := &Conn{
+		conn:                   conn,
+		br:                     br,
+		isServer:               isServer,
+		writerSize:             writeBufSize,
+		enableWriteCompression: true,
+		compressionLevel:       defaultCompressionLevel,
+	}
+	c.pingHandler = c.defaultPingHandler
+	c.pongHandler = c.defaultPongHandler
+	c.closeHandler = c.defaultCloseHandler
+	return c
+}
+
+// setCompression enables permessage-deflate for the connection using the
+// parameters negotiated during the handshake.
+func (c *Conn) setCompression(params deflateParams) {
+	c.compressionEnabled = true
+	c.writeNoContextTakeover = paramsNoContextTakeover(c.isServer, params)
+	c.readNoContextTakeover = paramsNoContextTakeover(!c.isServer, params)
 }
 
-func newConn(conn net.Conn, isServer bool) *Conn {
-	br := bufio.NewReaderSize(conn, defaultReaderSize)
-	return &Conn{conn: conn, br: br, isServer: isServer}
+// paramsNoContextTakeover reports whether the side writing messages (server
+// when forServer is true, client otherwise) must reset its flate state
+// between messages.
+func paramsNoContextTakeover(forServer bool, params deflateParams) bool {
+	if forServer {
+		return params.serverNoContextTakeover
+	}
+	return params.clientNoContextTakeover
 }
 
 func (c *Conn) Close() {
 	c.conn.Close()
 }
 
+// Subprotocol returns the negotiated application subprotocol, or "" if
+// none was requested or accepted during the handshake.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// SetReadDeadline sets the deadline for future calls to NextReader,
+// ReadMessage, and reads on a reader returned by NextReader. A zero value
+// disables the deadline, as with net.Conn.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future writes made via NextWriter,
+// WriteMessage, and WriteControl. A zero value disables the deadline, as
+// with net.Conn.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler sets the handler invoked when a ping control frame is
+// received. The appData argument is the PING frame payload. A nil handler
+// restores the default, which replies with a matching pong.
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = c.defaultPingHandler
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler sets the handler invoked when a pong control frame is
+// received. The appData argument is the PONG frame payload. A nil handler
+// restores the default, which does nothing.
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	if h == nil {
+		h = c.defaultPongHandler
+	}
+	c.pongHandler = h
+}
+
+// SetCloseHandler sets the handler invoked when a close control frame is
+// received. A nil handler restores the default, which echoes a close frame
+// with the same code back to the peer. The handler is called before
+// ReadMessage/NextReader return the resulting *CloseError.
+func (c *Conn) SetCloseHandler(h func(code int, text string) error) {
+	if h == nil {
+		h = c.defaultCloseHandler
+	}
+	c.closeHandler = h
+}
+
+func (c *Conn) defaultPingHandler(appData string) error {
+	return c.WriteControlDeadline(PongMessage, []byte(appData), time.Now().Add(writeWait))
+}
+
+func (c *Conn) defaultPongHandler(appData string) error {
+	return nil
+}
+
+func (c *Conn) defaultCloseHandler(code int, text string) error {
+	return c.WriteControlDeadline(CloseMessage, FormatCloseMessage(code, text), time.Now().Add(writeWait))
+}
+
 // write methods
 type msgWriter struct {
-	c   *Conn
-	bw  *bufio.Writer
-	fin bool
-	mt  int
-	ft  int
+	c    *Conn
+	bw   *bufio.Writer
+	fin  bool
+	mt   int
+	ft   int
+	rsv1 bool
 }
 
 func (w *msgWriter) ncopy(max int) (int, error) {
@@ -185,6 +334,9 @@ func (w *msgWriter) writeFrame(payload []byte) error {
 	if w.fin {
 		b0 |= finBit
 	}
+	if w.rsv1 && w.ft != ContMessage {
+		b0 |= rsv1Bit
+	}
 
 	w.bw.WriteByte(b0)
 
@@ -260,6 +412,7 @@ func (w *msgWriter) Write(p []byte) (int, error) {
 func (w *msgWriter) Close() error {
 	writer := w
 	w.c.writer = nil
+	defer w.c.writeMu.Unlock()
 	if err := writer.bw.Flush(); err != nil {
 		return err
 	}
@@ -271,18 +424,48 @@ func (c *Conn) NextWriter(mt int) (io.WriteCloser, error) {
 		return nil, errors.New("unclosed previous writer")
 	}
 
-	bw := bufio.NewWriterSize(c.conn, maxHeaderSize+defaultWriterSize)
+	c.writeMu.Lock()
+
+	bw := bufio.NewWriterSize(c.conn, maxHeaderSize+c.writerSize)
 	writer := &msgWriter{c: c, bw: bw, mt: mt}
+	writer.rsv1 = c.compressionEnabled && c.enableWriteCompression && !isControl(mt)
 
 	c.writer = writer
-	return c.writer, nil
+
+	if writer.rsv1 {
+		return c.newCompressWriter(writer), nil
+	}
+	return writer, nil
 }
 
+// WriteControl writes a control frame with no write deadline. It's safe to
+// call concurrently with NextWriter/WriteMessage and with other calls to
+// WriteControl/WriteControlDeadline.
 func (c *Conn) WriteControl(mt int, msg []byte) error {
+	return c.WriteControlDeadline(mt, msg, time.Time{})
+}
+
+// WriteControlDeadline is WriteControl with an explicit write deadline; a
+// zero deadline disables the deadline, as with net.Conn. It's intended for
+// use from a keepalive goroutine sending pings/pongs alongside a separate
+// goroutine that may be mid-NextWriter.
+func (c *Conn) WriteControlDeadline(mt int, msg []byte, deadline time.Time) error {
 	if !isControl(mt) {
 		return errBadMessageCode
 	}
 
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	// Control writes borrow the underlying conn's write deadline for the
+	// duration of this call; restore whatever the caller last set via
+	// SetWriteDeadline so this doesn't leak a stale deadline onto
+	// unrelated writes.
+	defer c.conn.SetWriteDeadline(c.writeDeadline)
+
 	var buf []byte
 	b0 := finBit | byte(mt)
 	b1 := byte(len(msg))
@@ -319,7 +502,11 @@ func (c *Conn) WriteMessage(mt int, msg []byte) error {
 		return err
 	}
 
+	// Always close the writer, even on a failed Write: NextWriter holds
+	// writeMu until Close, so abandoning it here would leak the lock and
+	// deadlock every later WriteControl/WriteControlDeadline call.
 	if _, err := w.Write(msg); err != nil {
+		w.Close()
 		return err
 	}
 	return w.Close()
@@ -336,69 +523,92 @@ func (c *Conn) read(n int) ([]byte, error) {
 	return p, nil
 }
 
-func (c *Conn) ReadMessage() (int, []byte, error) {
-	var msg []byte
-
-	messageType := noFrame
-	first := true
+// readFull reads exactly n bytes directly off the connection, unlike read,
+// which is limited to what fits in a single bufio.Reader.Peek (at most the
+// read buffer size). It's used for control frame payloads, which are
+// bounded to 125 bytes by the protocol, so either works; the data frame
+// path in frameReader uses c.br.Read directly so it isn't bounded by the
+// buffer size either.
+func (c *Conn) readFull(n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.br, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
 
+// readFrameHeader reads the header of the next data frame, transparently
+// handling (and dispatching) any control frames interleaved before it.
+// first indicates whether the caller is expecting the initial frame of a
+// new message (a Text/Binary opcode) or a continuation of one already in
+// progress.
+func (c *Conn) readFrameHeader(first bool) (frameType int, final, rsv1 bool, length int64, maskKey [4]byte, err error) {
 again:
 	p, err := c.read(2)
 	if err != nil {
-		return noFrame, nil, err
+		return noFrame, false, false, 0, maskKey, err
 	}
 
-	frameType := int(p[0] & 0xf)
-	final := p[0]&finBit != 0
-	rsv1 := p[0]&rsv1Bit != 0
+	frameType = int(p[0] & 0xf)
+	final = p[0]&finBit != 0
+	gotRsv1 := p[0]&rsv1Bit != 0
 	rsv2 := p[0]&rsv2Bit != 0
 	rsv3 := p[0]&rsv3Bit != 0
 
 	mask := p[1]&maskBit != 0
-	length := int64(p[1] & 0x7f)
+	length = int64(p[1] & 0x7f)
 
 	if mask != c.isServer {
-		return noFrame, nil, errors.New("bad MASK")
+		return noFrame, false, false, 0, maskKey, errors.New("bad MASK")
 	}
 
-	var errors []string
-	if rsv1 || rsv2 || rsv3 {
-		errors = append(errors, "RSV bits set")
+	var problems []string
+	if rsv2 || rsv3 {
+		problems = append(problems, "RSV bits set")
+	}
+	if gotRsv1 {
+		if !c.compressionEnabled || frameType == ContMessage || isControl(frameType) {
+			problems = append(problems, "RSV bits set")
+		} else {
+			rsv1 = true
+		}
 	}
 
 	switch frameType {
 	case CloseMessage, PingMessage, PongMessage:
 		if length > maxPayloadDate {
-			errors = append(errors, "len > 125 for control")
+			problems = append(problems, "len > 125 for control")
 		}
 		if !final {
-			errors = append(errors, "FIN not set on control")
+			problems = append(problems, "FIN not set on control")
 		}
 
 	case TextMessage, BinaryMessage:
-		messageType = frameType
 		if !first {
-			errors = append(errors, "data before FIN")
+			problems = append(problems, "data before FIN")
 		}
 
 	case ContMessage:
 		if first {
-			errors = append(errors, "continuation after FIN")
+			problems = append(problems, "continuation after FIN")
 		}
 
 	default:
-		errors = append(errors, "bad opcode "+strconv.Itoa(frameType))
+		problems = append(problems, "bad opcode "+strconv.Itoa(frameType))
 	}
 
-	if len(errors) > 0 {
-		return noFrame, nil, c.handleProtocolError(strings.Join(errors, ","))
+	if len(problems) > 0 {
+		return noFrame, false, false, 0, maskKey, c.handleProtocolError(strings.Join(problems, ","))
 	}
 
 	switch length {
 	case 126:
 		p, err := c.read(2)
 		if err != nil {
-			return noFrame, nil, err
+			return noFrame, false, false, 0, maskKey, err
 		}
 
 		length = int64(binary.BigEndian.Uint16(p))
@@ -406,54 +616,54 @@ again:
 	case 127:
 		p, err := c.read(8)
 		if err != nil {
-			return noFrame, nil, err
+			return noFrame, false, false, 0, maskKey, err
 		}
 
 		length = int64(binary.BigEndian.Uint64(p))
 	}
 
-	var maskKey [4]byte
 	if mask {
 		p, err := c.read(4)
 		if err != nil {
-			return noFrame, nil, err
+			return noFrame, false, false, 0, maskKey, err
 		}
 
 		copy(maskKey[:], p)
 	}
 
-	var buf []byte
-	for length > 0 {
-		p, err := c.read(int(length))
+	if isControl(frameType) {
+		buf, err := c.readFull(length)
 		if err != nil {
-			return noFrame, nil, err
+			return noFrame, false, false, 0, maskKey, err
 		}
 
-		buf = append(buf, p...)
-		length -= int64(len(p))
-	}
-
-	if mask {
-		for i := range buf {
-			buf[i] ^= maskKey[i%4]
+		if mask {
+			for i := range buf {
+				buf[i] ^= maskKey[i%4]
+			}
 		}
-	}
 
-	if isControl(frameType) {
 		if err := c.handleControl(frameType, buf); err != nil {
-			return noFrame, nil, err
-		}
-		if messageType != noFrame {
-			first = false
+			return noFrame, false, false, 0, maskKey, err
 		}
 		goto again
 	}
 
-	msg = append(msg, buf...)
+	return frameType, final, rsv1, length, maskKey, nil
+}
 
-	if !final {
-		first = false
-		goto again
+// ReadMessage reads the next message in its entirety. For large or
+// streamed payloads, prefer NextReader, which doesn't buffer the whole
+// message in memory.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType, r, err := c.NextReader()
+	if err != nil {
+		return noFrame, nil, err
+	}
+
+	msg, err := io.ReadAll(r)
+	if err != nil {
+		return noFrame, nil, err
 	}
 
 	return messageType, msg, nil
@@ -474,34 +684,23 @@ func (c *Conn) handleControl(mt int, payload []byte) error {
 				return c.handleProtocolError("bad close code")
 			}
 			text = string(payload[2:])
+			if !utf8.ValidString(text) {
+				return c.handleProtocolError("invalid UTF-8 in close reason")
+			}
 		}
-		c.handleClose(code, text)
+		_ = c.closeHandler(code, text)
 
 		return &CloseError{Code: code, Text: text}
 
 	case PingMessage:
-		return c.handlePing(payload)
+		return c.pingHandler(string(payload))
 	case PongMessage:
-		return c.handlePong()
+		return c.pongHandler(string(payload))
 	}
 
 	return nil
 }
 
-func (c *Conn) handleClose(code int, text string) error {
-	_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
-	return nil
-}
-
-func (c *Conn) handlePing(payload []byte) error {
-	_ = c.WriteControl(PongMessage, payload)
-	return nil
-}
-
-func (c *Conn) handlePong() error {
-	return nil
-}
-
 func (c *Conn) handleProtocolError(message string) error {
 	data := FormatCloseMessage(CloseProtocolError, message)
 	c.WriteMessage(CloseMessage, data)
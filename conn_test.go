@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestControlWriteRestoresDeadline guards against a control write (as used
+// by the default ping/close handlers) leaking its own temporary deadline
+// onto unrelated writes made afterwards with no deadline of their own.
+func TestControlWriteRestoresDeadline(t *testing.T) {
+	clientRaw, serverRaw := net.Pipe()
+	defer clientRaw.Close()
+	defer serverRaw.Close()
+
+	server := newConn(serverRaw, true, 0, 0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 4096)
+		for {
+			if _, err := clientRaw.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := server.WriteControlDeadline(PongMessage, []byte("keepalive"), time.Now().Add(30*time.Millisecond)); err != nil {
+		t.Fatalf("control write: %v", err)
+	}
+
+	// Long enough for the control write's deadline to have elapsed; if it
+	// leaked onto the connection, the write below fails with i/o timeout.
+	time.Sleep(100 * time.Millisecond)
+
+	if err := server.WriteMessage(TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("unrelated write after control write failed, likely due to a leaked deadline: %v", err)
+	}
+
+	serverRaw.Close()
+	<-done
+}
+
+// TestWriteMessageErrorReleasesWriteLock guards against a failed
+// WriteMessage leaking writeMu (locked by NextWriter, unlocked only by
+// msgWriter.Close): every later WriteControl/WriteControlDeadline call,
+// including the automatic pong/close replies, must not deadlock.
+func TestWriteMessageErrorReleasesWriteLock(t *testing.T) {
+	clientRaw, serverRaw := net.Pipe()
+	defer serverRaw.Close()
+	clientRaw.Close()
+
+	server := newConn(serverRaw, true, 0, 0)
+
+	// Bigger than the write buffer, so the error surfaces mid-Write (via
+	// a Flush inside msgWriter.ncopy) rather than only in the later Close.
+	big := make([]byte, 4*defaultWriterSize)
+	if err := server.WriteMessage(TextMessage, big); err == nil {
+		t.Fatal("expected write to a closed pipe to fail")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.WriteControl(PongMessage, []byte("x"))
+	}()
+
+	select {
+	case <-done:
+		// Any outcome (success or failure) is fine; the point is that it
+		// didn't hang waiting on writeMu.
+	case <-time.After(2 * time.Second):
+		t.Fatal("WriteControl deadlocked after a failed WriteMessage left writeMu locked")
+	}
+}
@@ -0,0 +1,252 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	errBadUrl       = errors.New("bad ws or wss url")
+	errBadHandshake = errors.New("bad handshake")
+)
+
+// Dialer configures how a client connects to a WebSocket server.
+type Dialer struct {
+	// Subprotocols is the list of application subprotocols to offer, in
+	// order of preference. Conn.Subprotocol reports the one the server
+	// accepted, if any.
+	Subprotocols []string
+
+	// TLSClientConfig configures the TLS handshake used for wss:// URLs.
+	// A nil value uses the standard library's defaults.
+	TLSClientConfig *tls.Config
+
+	// Proxy, if non-nil, returns the proxy to use for the given connect
+	// request, or a nil URL to dial directly.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// NetDial, if non-nil, is used to dial the underlying TCP connection
+	// (either directly to the server or to the proxy). A nil value uses
+	// net.Dial.
+	NetDial func(network, addr string) (net.Conn, error)
+
+	// Jar, if non-nil, supplies request cookies and stores response
+	// cookies, just like http.Client's Jar.
+	Jar http.CookieJar
+}
+
+// Dial opens a new client connection to wsUrl using default Dialer settings.
+func Dial(wsUrl string) (*Conn, error) {
+	return (&Dialer{}).Dial(wsUrl)
+}
+
+func (d *Dialer) Dial(wsUrl string) (*Conn, error) {
+	u, err := url.Parse(wsUrl)
+	if err != nil {
+		return nil, errBadUrl
+	}
+
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, errBadUrl
+	}
+
+	challengeKey, err := generateChallengeKey()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+
+	req.Header.Add("Upgrade", "websocket")
+	req.Header.Add("Connection", "Upgrade")
+	req.Header.Add("Sec-WebSocket-Version", "13")
+	req.Header.Add("Sec-WebSocket-Key", challengeKey)
+	req.Header.Add("Sec-WebSocket-Extensions", deflateOffer())
+	if len(d.Subprotocols) > 0 {
+		req.Header.Add("Sec-WebSocket-Protocol", strings.Join(d.Subprotocols, ", "))
+	}
+	if d.Jar != nil {
+		for _, c := range d.Jar.Cookies(u) {
+			req.AddCookie(c)
+		}
+	}
+
+	netConn, err := d.dialHost(req, u)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "wss" {
+		netConn, err = d.tlsHandshake(netConn, u.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	conn := newConn(netConn, false, 0, 0)
+	resp, err := http.ReadResponse(conn.br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !validateResponse(resp) ||
+		resp.Header.Get("Sec-WebSocket-Accept") != computeAcceptKey(challengeKey) {
+		conn.Close()
+		return nil, errBadHandshake
+	}
+
+	if d.Jar != nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			d.Jar.SetCookies(u, cookies)
+		}
+	}
+
+	if params, ok := negotiateDeflateClient(resp.Header.Get("Sec-WebSocket-Extensions")); ok {
+		conn.setCompression(params)
+	}
+
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		if !containsString(d.Subprotocols, protocol) {
+			conn.Close()
+			return nil, errors.New("websocket: server selected unsupported protocol")
+		}
+		conn.subprotocol = protocol
+	}
+
+	return conn, nil
+}
+
+// dialHost dials the TCP connection the handshake will run over: either
+// straight to the target host, or to a proxy with a CONNECT tunnel through
+// to the target host.
+func (d *Dialer) dialHost(req *http.Request, u *url.URL) (net.Conn, error) {
+	var proxyURL *url.URL
+	if d.Proxy != nil {
+		var err error
+		proxyURL, err = d.Proxy(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dialAddr := u.Host
+	if proxyURL != nil {
+		dialAddr = proxyURL.Host
+	}
+
+	netConn, err := d.netDial("tcp", dialAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL != nil {
+		if err := connectProxy(netConn, u.Host); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return netConn, nil
+}
+
+func (d *Dialer) netDial(network, addr string) (net.Conn, error) {
+	if d.NetDial != nil {
+		return d.NetDial(network, addr)
+	}
+	return net.Dial(network, addr)
+}
+
+func (d *Dialer) tlsHandshake(netConn net.Conn, host string) (net.Conn, error) {
+	tlsConfig := d.TLSClientConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = hostOnly(host)
+	}
+
+	tlsConn := tls.Client(netConn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func hostOnly(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+	return host
+}
+
+// connectProxy issues an HTTP CONNECT request over conn to tunnel through
+// to targetHost.
+func connectProxy(conn net.Conn, targetHost string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: targetHost},
+		Host:   targetHost,
+		Header: make(http.Header),
+	}
+
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("websocket: proxy CONNECT failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func validateResponse(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusSwitchingProtocols &&
+		checkHeader(resp.Header, "Upgrade", "websocket") &&
+		checkHeader(resp.Header, "Connection", "Upgrade")
+}
+
+func checkHeader(header http.Header, key, value string) bool {
+	vals := header.Values(key)
+	if len(vals) == 1 {
+		return vals[0] == value
+	}
+	return false
+}
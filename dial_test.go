@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"net"
+	"testing"
+)
+
+// TestDialRejectsBadHandshake guards against regressing the 101 response
+// check back into dead code: a server that replies with something other
+// than a valid upgrade response must not be accepted as a websocket peer.
+func TestDialRejectsBadHandshake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 4096)
+		conn.Read(buf)
+		conn.Write([]byte("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"))
+	}()
+
+	if _, err := Dial("ws://" + ln.Addr().String()); err == nil {
+		t.Fatal("Dial succeeded against a non-websocket response; want an error")
+	}
+}
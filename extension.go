@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"strconv"
+	"strings"
+)
+
+const permessageDeflate = "permessage-deflate"
+
+// deflateParams holds the negotiated parameters for a permessage-deflate
+// extension instance, as defined by RFC 7692 section 7.1.
+type deflateParams struct {
+	clientNoContextTakeover bool
+	serverNoContextTakeover bool
+	clientMaxWindowBits     int
+	serverMaxWindowBits     int
+}
+
+// parseExtensions splits a Sec-WebSocket-Extensions header value into its
+// comma-separated offers, each broken into its semicolon-separated tokens.
+func parseExtensions(header string) [][]string {
+	var exts [][]string
+	for _, offer := range strings.Split(header, ",") {
+		var tokens []string
+		for _, tok := range strings.Split(offer, ";") {
+			if tok = strings.TrimSpace(tok); tok != "" {
+				tokens = append(tokens, tok)
+			}
+		}
+		if len(tokens) > 0 {
+			exts = append(exts, tokens)
+		}
+	}
+	return exts
+}
+
+// splitParam splits a single "name" or "name=value" extension token.
+func splitParam(tok string) (name, value string) {
+	if i := strings.IndexByte(tok, '='); i >= 0 {
+		return strings.TrimSpace(tok[:i]), strings.Trim(strings.TrimSpace(tok[i+1:]), `"`)
+	}
+	return strings.TrimSpace(tok), ""
+}
+
+// negotiateDeflateServer looks for a permessage-deflate offer in a client's
+// Sec-WebSocket-Extensions header and, if found, returns the parameters to
+// use along with the value the server should echo back to accept it.
+func negotiateDeflateServer(header string) (params deflateParams, response string, ok bool) {
+	for _, tokens := range parseExtensions(header) {
+		if !strings.EqualFold(tokens[0], permessageDeflate) {
+			continue
+		}
+
+		params = deflateParams{clientMaxWindowBits: 15, serverMaxWindowBits: 15}
+		resp := []string{permessageDeflate}
+
+		for _, tok := range tokens[1:] {
+			name, value := splitParam(tok)
+			switch name {
+			case "client_no_context_takeover":
+				params.clientNoContextTakeover = true
+				resp = append(resp, name)
+			case "server_no_context_takeover":
+				params.serverNoContextTakeover = true
+				resp = append(resp, name)
+			case "client_max_window_bits":
+				if bits, err := strconv.Atoi(value); err == nil {
+					params.clientMaxWindowBits = bits
+				}
+				resp = append(resp, name)
+			case "server_max_window_bits":
+				if bits, err := strconv.Atoi(value); err == nil {
+					params.serverMaxWindowBits = bits
+					resp = append(resp, name+"="+value)
+				}
+			}
+		}
+
+		return params, strings.Join(resp, "; "), true
+	}
+
+	return deflateParams{}, "", false
+}
+
+// negotiateDeflateClient parses the server's response extensions for the
+// permessage-deflate parameters it accepted for a Dial offer.
+func negotiateDeflateClient(header string) (params deflateParams, ok bool) {
+	for _, tokens := range parseExtensions(header) {
+		if !strings.EqualFold(tokens[0], permessageDeflate) {
+			continue
+		}
+
+		params = deflateParams{clientMaxWindowBits: 15, serverMaxWindowBits: 15}
+		for _, tok := range tokens[1:] {
+			name, value := splitParam(tok)
+			switch name {
+			case "client_no_context_takeover":
+				params.clientNoContextTakeover = true
+			case "server_no_context_takeover":
+				params.serverNoContextTakeover = true
+			case "client_max_window_bits":
+				if bits, err := strconv.Atoi(value); err == nil {
+					params.clientMaxWindowBits = bits
+				}
+			case "server_max_window_bits":
+				if bits, err := strconv.Atoi(value); err == nil {
+					params.serverMaxWindowBits = bits
+				}
+			}
+		}
+
+		return params, true
+	}
+
+	return deflateParams{}, false
+}
+
+// deflateOffer builds the Sec-WebSocket-Extensions value Dial sends to
+// request permessage-deflate.
+func deflateOffer() string {
+	return permessageDeflate + "; client_max_window_bits"
+}
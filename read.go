@@ -0,0 +1,126 @@
+package websocket
+
+import "io"
+
+// frameReader streams a single message's payload directly off the
+// connection, fetching and unmasking successive continuation frames as
+// needed instead of buffering the whole message in memory.
+type frameReader struct {
+	c         *Conn
+	remaining int64
+	pos       int64
+	maskKey   [4]byte
+	mask      bool
+	final     bool
+}
+
+func (fr *frameReader) Read(p []byte) (int, error) {
+	for fr.remaining == 0 {
+		if fr.final {
+			return 0, io.EOF
+		}
+
+		_, final, _, length, maskKey, err := fr.c.readFrameHeader(false)
+		if err != nil {
+			return 0, err
+		}
+
+		fr.remaining = length
+		fr.maskKey = maskKey
+		fr.final = final
+		fr.pos = 0
+	}
+
+	if int64(len(p)) > fr.remaining {
+		p = p[:fr.remaining]
+	}
+
+	n, err := fr.c.br.Read(p)
+	if n > 0 {
+		if fr.mask {
+			for i := 0; i < n; i++ {
+				p[i] ^= fr.maskKey[(fr.pos+int64(i))%4]
+			}
+		}
+		fr.pos += int64(n)
+		fr.remaining -= int64(n)
+	}
+
+	if err == io.EOF {
+		return n, errUnexpectedEOF
+	}
+	return n, err
+}
+
+// utf8Reader wraps a text message's reader, validating the decoded bytes
+// are well-formed UTF-8 as they're read (RFC 6455 section 8.1). An invalid
+// or incomplete-at-EOF encoding fails the connection like any other
+// protocol error.
+type utf8Reader struct {
+	c *Conn
+	r io.Reader
+	v utf8Validator
+}
+
+func (u *utf8Reader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	if n > 0 && !u.v.write(p[:n]) {
+		return n, u.c.handleProtocolError("invalid UTF-8 text payload")
+	}
+	if err == io.EOF && !u.v.valid() {
+		return n, u.c.handleProtocolError("invalid UTF-8 text payload")
+	}
+	return n, err
+}
+
+// limitReader enforces Conn.readLimit over a message's reader, failing the
+// connection with a CloseMessageTooBig close frame once exceeded.
+type limitReader struct {
+	c     *Conn
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		_ = l.c.WriteControl(CloseMessage, FormatCloseMessage(CloseMessageTooBig, ""))
+		return n, &CloseError{Code: CloseMessageTooBig}
+	}
+	return n, err
+}
+
+// NextReader returns the type and a reader for the next message on the
+// connection. Unlike ReadMessage, the returned reader streams the message
+// directly off the wire instead of buffering it whole, so it's suitable
+// for large or unbounded messages. The reader must be fully consumed (or
+// its error observed) before the next call to NextReader or ReadMessage.
+func (c *Conn) NextReader() (int, io.Reader, error) {
+	frameType, final, rsv1, length, maskKey, err := c.readFrameHeader(true)
+	if err != nil {
+		return noFrame, nil, err
+	}
+
+	var r io.Reader = &frameReader{c: c, remaining: length, maskKey: maskKey, mask: c.isServer, final: final}
+	if rsv1 {
+		r = c.newCompressedReader(r)
+	}
+	if frameType == TextMessage {
+		r = &utf8Reader{c: c, r: r}
+	}
+	if c.readLimit > 0 {
+		r = &limitReader{c: c, r: r, limit: c.readLimit}
+	}
+
+	return frameType, r, nil
+}
+
+// SetReadLimit sets the maximum size, in bytes, of a message NextReader or
+// ReadMessage will return. Reads past the limit fail the connection with a
+// CloseMessageTooBig (1009) close frame and return a *CloseError. Zero (the
+// default) means no limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
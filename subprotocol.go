@@ -0,0 +1,28 @@
+package websocket
+
+import "strings"
+
+// parseSubprotocols splits a Sec-WebSocket-Protocol header value into its
+// comma-separated tokens.
+func parseSubprotocols(header string) []string {
+	var protocols []string
+	for _, p := range strings.Split(header, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			protocols = append(protocols, p)
+		}
+	}
+	return protocols
+}
+
+// negotiateSubprotocol picks the first protocol the client offered that
+// also appears in the server's supported list, or "" if none match.
+func negotiateSubprotocol(serverProtocols []string, header string) string {
+	for _, offered := range parseSubprotocols(header) {
+		for _, supported := range serverProtocols {
+			if offered == supported {
+				return offered
+			}
+		}
+	}
+	return ""
+}
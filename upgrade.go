@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Upgrader configures how an incoming HTTP request is upgraded to a
+// WebSocket connection.
+type Upgrader struct {
+	// ReadBufferSize and WriteBufferSize size the connection's I/O
+	// buffers. Zero uses a sensible default.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// HandshakeTimeout bounds how long the upgrade may take. Zero means
+	// no timeout.
+	HandshakeTimeout time.Duration
+
+	// Subprotocols is the list of application subprotocols the server
+	// supports, in order of preference among ties in the client's offer.
+	Subprotocols []string
+
+	// CheckOrigin returns true if the request's Origin header is
+	// acceptable. If nil, a same-origin check is used, rejecting
+	// cross-origin WebSocket handshakes (CSWSH).
+	CheckOrigin func(r *http.Request) bool
+
+	// Error, if set, is called instead of writing a bare text response
+	// when the upgrade fails.
+	Error func(w http.ResponseWriter, r *http.Request, status int, reason error)
+}
+
+// Accept upgrades the HTTP request to a WebSocket connection using default
+// Upgrader settings.
+func Accept(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	return (&Upgrader{}).Upgrade(w, r, nil)
+}
+
+func (u *Upgrader) writeError(w http.ResponseWriter, r *http.Request, status int, msg string) error {
+	err := errors.New(msg)
+	if u.Error != nil {
+		u.Error(w, r, status, err)
+		return err
+	}
+	return writeError(status, msg, w)
+}
+
+func writeError(code int, msg string, w http.ResponseWriter) error {
+	w.WriteHeader(code)
+	w.Write([]byte(msg))
+	return errors.New(msg)
+}
+
+// checkSameOrigin is the default CheckOrigin: it rejects the handshake
+// unless the Origin header names the same host the request was sent to.
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(originURL.Host, r.Host)
+}
+
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	const badHandshake = "websocket: bad client handshake: "
+	if !checkHeader(r.Header, "Connection", "Upgrade") {
+		return nil, u.writeError(w, r, http.StatusBadRequest, badHandshake+"no 'Connection' header")
+	}
+
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, u.writeError(w, r, http.StatusBadRequest, badHandshake+"no 'Upgrade' header")
+	}
+
+	if r.Method != http.MethodGet {
+		return nil, u.writeError(w, r, http.StatusMethodNotAllowed, badHandshake+"not GET method")
+	}
+
+	if !checkHeader(r.Header, "Sec-WebSocket-Version", "13") {
+		return nil, u.writeError(w, r, http.StatusBadRequest, badHandshake+"unsupported websocket version")
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.writeError(w, r, http.StatusForbidden, badHandshake+"origin not allowed")
+	}
+
+	challengeKey := r.Header.Get("Sec-WebSocket-Key")
+	if challengeKey == "" {
+		return nil, u.writeError(w, r, http.StatusBadRequest, badHandshake+"no challenge key")
+	}
+
+	acceptKey := computeAcceptKey(challengeKey)
+
+	deflate, extensionResponse, negotiatedDeflate := negotiateDeflateServer(r.Header.Get("Sec-WebSocket-Extensions"))
+	subprotocol := negotiateSubprotocol(u.Subprotocols, r.Header.Get("Sec-WebSocket-Protocol"))
+
+	for k, vs := range responseHeader {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+
+	w.Header().Set("Upgrade", "websocket")
+	w.Header().Set("Connection", "Upgrade")
+	w.Header().Set("Sec-WebSocket-Accept", acceptKey)
+	if negotiatedDeflate {
+		w.Header().Set("Sec-WebSocket-Extensions", extensionResponse)
+	}
+	if subprotocol != "" {
+		w.Header().Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+
+	// Bound the 101 response write itself; reading the client's request
+	// already happened before this handler ran, so the write (and the
+	// Hijack immediately after it) is the only handshake I/O left for
+	// HandshakeTimeout to cover.
+	if u.HandshakeTimeout != 0 {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(u.HandshakeTimeout)); err != nil {
+			return nil, u.writeError(w, r, http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	w.WriteHeader(http.StatusSwitchingProtocols)
+
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, u.writeError(w, r, http.StatusInternalServerError, "websocket: response does not implement hijacker")
+	}
+
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, u.writeError(w, r, http.StatusInternalServerError, err.Error())
+	}
+
+	if u.HandshakeTimeout != 0 {
+		netConn.SetDeadline(time.Time{})
+	}
+
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data with handshake")
+	}
+
+	conn := newConn(netConn, true, u.ReadBufferSize, u.WriteBufferSize)
+	if negotiatedDeflate {
+		conn.setCompression(deflate)
+	}
+	conn.subprotocol = subprotocol
+	return conn, nil
+}
@@ -0,0 +1,49 @@
+package websocket
+
+import "unicode/utf8"
+
+// utf8Validator incrementally checks that a byte stream is well-formed
+// UTF-8, as RFC 6455 section 8.1 requires for text message payloads and
+// close reasons. It buffers at most the trailing bytes of a rune that
+// hasn't been completed yet, so validation works across writes that split
+// a multi-byte rune at an arbitrary point (e.g. across fragmented frames
+// or NextReader chunks).
+type utf8Validator struct {
+	pending [utf8.UTFMax]byte
+	n       int
+}
+
+// write validates p as a continuation of whatever was buffered from the
+// previous call, reporting false as soon as an invalid encoding is found.
+func (v *utf8Validator) write(p []byte) bool {
+	data := p
+	if v.n > 0 {
+		data = append(append([]byte(nil), v.pending[:v.n]...), p...)
+		v.n = 0
+	}
+
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r != utf8.RuneError || size > 1 {
+			data = data[size:]
+			continue
+		}
+
+		// size <= 1: either a genuinely invalid byte, or a valid rune
+		// prefix that's incomplete because it's been split across writes.
+		if !utf8.FullRune(data) {
+			v.n = copy(v.pending[:], data)
+			return true
+		}
+		return false
+	}
+
+	return true
+}
+
+// valid reports whether the stream seen so far ends on a complete rune,
+// i.e. no partial encoding is left dangling once the stream is known to
+// have ended.
+func (v *utf8Validator) valid() bool {
+	return v.n == 0
+}